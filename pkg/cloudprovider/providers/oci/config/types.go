@@ -0,0 +1,98 @@
+// Copyright 2017 Oracle and/or its affiliates. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+// Config holds the configuration for the CCM, including authentication
+// credentials for the OCI API and tunables for the cloud provider features
+// it implements (currently only the load balancer controller).
+type Config struct {
+	Auth AuthConfig `yaml:"auth"`
+
+	// VCNID is the OCID of the VCN the worker nodes are attached to. Only
+	// required when LoadBalancer.Subnet1 is not set.
+	VCNID string `yaml:"vcn"`
+
+	LoadBalancer *LoadBalancerConfig `yaml:"loadBalancer"`
+}
+
+// AuthConfig holds the configuration required for communicating with the
+// OCI API.
+//
+// Exactly one authentication mode must be configured:
+//
+//   - User principal (the default): Region, TenancyID, UserID, PrivateKey,
+//     and Fingerprint must all be set. CompartmentID is optional.
+//   - Instance principal: UseInstancePrincipals must be true and none of
+//     the user principal fields may be set.
+//   - Resource principal: UseResourcePrincipals must be true and none of
+//     the user principal fields may be set. The session is built entirely
+//     from the OCI_RESOURCE_PRINCIPAL_* environment variables.
+type AuthConfig struct {
+	Region        string `yaml:"region"`
+	TenancyID     string `yaml:"tenancy"`
+	CompartmentID string `yaml:"compartment"`
+	UserID        string `yaml:"user"`
+	PrivateKey    string `yaml:"key"`
+	Fingerprint   string `yaml:"fingerprint"`
+
+	// UseInstancePrincipals, when true, configures the client to
+	// authenticate as the instance principal of the node the CCM is
+	// running on rather than via the user principal fields above.
+	UseInstancePrincipals bool `yaml:"useInstancePrincipals"`
+
+	// UseResourcePrincipals, when true, configures the client to
+	// authenticate via the OCI Resource Principal Session Token (RPST)
+	// flow, as made available to OCI Functions and OKE workloads. Mutually
+	// exclusive with UseInstancePrincipals.
+	UseResourcePrincipals bool `yaml:"useResourcePrincipals"`
+
+	// DelegationTokenPath is the path to a Kubernetes-projected delegation
+	// token file. When set alongside UseInstancePrincipals, the client
+	// authenticates as the instance principal but additionally presents
+	// the delegation token so that OCI authorizes the call against the
+	// identity of the OKE user who triggered the action rather than the
+	// node's own identity. The file is re-read whenever its mtime changes,
+	// since Kubernetes rotates projected tokens in place.
+	DelegationTokenPath string `yaml:"delegationTokenPath"`
+}
+
+// LoadBalancerConfig holds the configuration options for the load balancer
+// controller.
+type LoadBalancerConfig struct {
+	Disabled bool `yaml:"disabled"`
+
+	// Subnet1 and Subnet2 are the OCIDs of the subnets the controller will
+	// place load balancers into. If unset the controller falls back to
+	// creating subnets in the VCN identified by Config.VCNID.
+	Subnet1 string `yaml:"subnet1"`
+	Subnet2 string `yaml:"subnet2"`
+
+	// SecurityListManagementMode configures how the controller manages the
+	// security lists of the subnets/NSGs backing a load balancer. Defaults
+	// to ManagementModeAll.
+	SecurityListManagementMode string `yaml:"securityListManagementMode"`
+}
+
+const (
+	// ManagementModeAll denotes that the CCM should manage all the security
+	// list rules required by a load balancer.
+	ManagementModeAll = "All"
+	// ManagementModeFrontend denotes that the CCM should manage only the
+	// frontend (ingress) security list rules required by a load balancer.
+	ManagementModeFrontend = "Frontend"
+	// ManagementModeNone denotes that the CCM should not manage any
+	// security list rules for a load balancer.
+	ManagementModeNone = "None"
+)
@@ -0,0 +1,103 @@
+// Copyright 2017 Oracle and/or its affiliates. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// Complete fills in any fields that are not set that are required to have
+// valid values and applies any defaults.
+func (c *Config) Complete() {
+	if c.LoadBalancer != nil && c.LoadBalancer.SecurityListManagementMode == "" {
+		c.LoadBalancer.SecurityListManagementMode = ManagementModeAll
+	}
+}
+
+// usesPrincipalAuth reports whether the config is configured to authenticate
+// via either instance principals or resource principals.
+func (c *AuthConfig) usesPrincipalAuth() bool {
+	return c.UseInstancePrincipals || c.UseResourcePrincipals
+}
+
+func validateAuthConfig(c *AuthConfig) field.ErrorList {
+	errs := field.ErrorList{}
+
+	if c.UseInstancePrincipals && c.UseResourcePrincipals {
+		errs = append(errs, field.Invalid(field.NewPath("auth", "useResourcePrincipals"), c.UseResourcePrincipals, "cannot be used in conjunction with useInstancePrincipals"))
+		return errs
+	}
+
+	if c.DelegationTokenPath != "" && !c.UseInstancePrincipals {
+		errs = append(errs, field.Invalid(field.NewPath("auth", "delegationTokenPath"), c.DelegationTokenPath, "can only be used in conjunction with useInstancePrincipals"))
+		return errs
+	}
+
+	if c.usesPrincipalAuth() {
+		if c.Region != "" || c.TenancyID != "" || c.CompartmentID != "" || c.UserID != "" || c.PrivateKey != "" || c.Fingerprint != "" {
+			errs = append(errs, field.Invalid(field.NewPath("auth"), "", "user principal configuration cannot be provided in conjunction with instance or resource principals"))
+		}
+		return errs
+	}
+
+	if c.Region == "" {
+		errs = append(errs, field.Required(field.NewPath("auth", "region"), ""))
+	}
+	if c.TenancyID == "" {
+		errs = append(errs, field.Required(field.NewPath("auth", "tenancy"), ""))
+	}
+	if c.UserID == "" {
+		errs = append(errs, field.Required(field.NewPath("auth", "user"), ""))
+	}
+	if c.PrivateKey == "" {
+		errs = append(errs, field.Required(field.NewPath("auth", "key"), ""))
+	}
+	if c.Fingerprint == "" {
+		errs = append(errs, field.Required(field.NewPath("auth", "fingerprint"), ""))
+	}
+
+	return errs
+}
+
+func validateLoadBalancerConfig(c *Config) field.ErrorList {
+	errs := field.ErrorList{}
+
+	lb := c.LoadBalancer
+	if lb == nil {
+		return errs
+	}
+
+	if lb.Subnet1 == "" && c.VCNID == "" {
+		errs = append(errs, field.Required(field.NewPath("vcn"), "VCNID configuration must be provided if configuration for subnet1 is not provided"))
+	}
+
+	switch lb.SecurityListManagementMode {
+	case ManagementModeAll, ManagementModeFrontend, ManagementModeNone:
+	default:
+		errs = append(errs, field.Invalid(field.NewPath("loadBalancer", "securityListManagementMode"), lb.SecurityListManagementMode, "invalid security list management mode"))
+	}
+
+	return errs
+}
+
+// ValidateConfig validates the CCM config and returns a list of errors found.
+// Call Config.Complete() first so that defaulted fields are taken into
+// account.
+func ValidateConfig(c *Config) field.ErrorList {
+	errs := field.ErrorList{}
+	errs = append(errs, validateAuthConfig(&c.Auth)...)
+	errs = append(errs, validateLoadBalancerConfig(c)...)
+	return errs
+}
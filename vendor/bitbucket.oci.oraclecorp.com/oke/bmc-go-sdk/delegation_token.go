@@ -0,0 +1,50 @@
+// Copyright (c) 2017, Oracle and/or its affiliates. All rights reserved.
+
+package baremetal
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// delegationTokenSource caches the contents of a projected delegation token
+// file, reloading it only when the file's mtime changes. Kubernetes
+// rewrites projected service-account-style tokens in place on rotation, so
+// a naive read-once would eventually present an expired token.
+type delegationTokenSource struct {
+	path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	token   string
+}
+
+func newDelegationTokenSource(path string) *delegationTokenSource {
+	return &delegationTokenSource{path: path}
+}
+
+// Token returns the current delegation token, reloading it from disk if the
+// underlying file has changed since the last read.
+func (d *delegationTokenSource) Token() (string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	info, err := os.Stat(d.path)
+	if err != nil {
+		return "", err
+	}
+
+	if !info.ModTime().Equal(d.modTime) {
+		contents, err := ioutil.ReadFile(d.path)
+		if err != nil {
+			return "", err
+		}
+		d.token = strings.TrimSpace(string(contents))
+		d.modTime = info.ModTime()
+	}
+
+	return d.token, nil
+}
@@ -0,0 +1,178 @@
+// Copyright (c) 2017, Oracle and/or its affiliates. All rights reserved.
+
+// Package rate is a minimal internal token-bucket limiter shaped after the
+// public API of golang.org/x/time/rate (Limiter, NewLimiter, Wait, SetLimit,
+// ...). It is NOT a vendored copy of that module: this repo vendors
+// dependencies the old GOPATH way with no go.mod/Gopkg to pull x/time in,
+// and this tree has no network access to fetch it, so adaptiveRateLimiter in
+// ratelimit.go depends on this internal package instead. It omits parts of
+// that API, such as Reservation.
+package rate
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limit defines the maximum frequency of some events. Limit is represented
+// as number of events per second. A zero Limit allows no events.
+type Limit float64
+
+// Inf is the infinite rate limit; it allows all events (even if burst is zero).
+const Inf = Limit(math.MaxFloat64)
+
+// Every converts a minimum time interval between events to a Limit.
+func Every(interval time.Duration) Limit {
+	if interval <= 0 {
+		return Inf
+	}
+	return 1 / Limit(interval.Seconds())
+}
+
+// A Limiter controls how frequently events are allowed to happen. It
+// implements a token bucket of size b, initially full and refilled at rate r
+// tokens per second.
+type Limiter struct {
+	mu     sync.Mutex
+	limit  Limit
+	burst  int
+	tokens float64
+	last   time.Time
+}
+
+// NewLimiter returns a new Limiter that allows events up to rate r and
+// permits bursts of at most b tokens.
+func NewLimiter(r Limit, b int) *Limiter {
+	return &Limiter{
+		limit:  r,
+		burst:  b,
+		tokens: float64(b),
+		last:   time.Now(),
+	}
+}
+
+// Limit returns the maximum overall event rate.
+func (lim *Limiter) Limit() Limit {
+	lim.mu.Lock()
+	defer lim.mu.Unlock()
+	return lim.limit
+}
+
+// Burst returns the maximum burst size.
+func (lim *Limiter) Burst() int {
+	lim.mu.Lock()
+	defer lim.mu.Unlock()
+	return lim.burst
+}
+
+// SetLimit sets a new Limit for the limiter, taking immediate effect.
+func (lim *Limiter) SetLimit(newLimit Limit) {
+	lim.mu.Lock()
+	defer lim.mu.Unlock()
+	lim.advanceLocked(time.Now())
+	lim.limit = newLimit
+}
+
+// SetBurst sets a new burst size for the limiter.
+func (lim *Limiter) SetBurst(newBurst int) {
+	lim.mu.Lock()
+	defer lim.mu.Unlock()
+	lim.advanceLocked(time.Now())
+	lim.burst = newBurst
+	if lim.tokens > float64(newBurst) {
+		lim.tokens = float64(newBurst)
+	}
+}
+
+// Allow reports whether an event may happen now.
+func (lim *Limiter) Allow() bool {
+	return lim.AllowN(time.Now(), 1)
+}
+
+// AllowN reports whether n events may happen at time now.
+func (lim *Limiter) AllowN(now time.Time, n int) bool {
+	lim.mu.Lock()
+	defer lim.mu.Unlock()
+
+	lim.advanceLocked(now)
+	if lim.limit == Inf {
+		return true
+	}
+	if lim.tokens >= float64(n) {
+		lim.tokens -= float64(n)
+		return true
+	}
+	return false
+}
+
+// Wait blocks until the limiter permits one event to happen, or returns an
+// error if ctx is cancelled first.
+func (lim *Limiter) Wait(ctx context.Context) error {
+	return lim.WaitN(ctx, 1)
+}
+
+// WaitN blocks until the limiter permits n events to happen, or returns an
+// error if ctx is cancelled first, or if n exceeds the limiter's burst size.
+func (lim *Limiter) WaitN(ctx context.Context, n int) error {
+	lim.mu.Lock()
+	burst := lim.burst
+	limit := lim.limit
+	lim.mu.Unlock()
+
+	if n > burst && limit != Inf {
+		return fmt.Errorf("rate: Wait(n=%d) exceeds limiter's burst %d", n, burst)
+	}
+
+	for {
+		now := time.Now()
+
+		lim.mu.Lock()
+		lim.advanceLocked(now)
+		if lim.limit == Inf || lim.tokens >= float64(n) {
+			lim.tokens -= float64(n)
+			lim.mu.Unlock()
+			return nil
+		}
+		var wait time.Duration
+		if lim.limit > 0 {
+			need := float64(n) - lim.tokens
+			wait = time.Duration(need / float64(lim.limit) * float64(time.Second))
+		}
+		lim.mu.Unlock()
+
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// advanceLocked updates lim.tokens and lim.last to reflect the passage of
+// time up to now. lim.mu must be held.
+func (lim *Limiter) advanceLocked(now time.Time) {
+	last := lim.last
+	if now.Before(last) {
+		last = now
+	}
+
+	if lim.limit == Inf {
+		lim.tokens = float64(lim.burst)
+		lim.last = now
+		return
+	}
+
+	elapsed := now.Sub(last)
+	delta := float64(lim.limit) * elapsed.Seconds()
+	tokens := lim.tokens + delta
+	if burst := float64(lim.burst); tokens > burst {
+		tokens = burst
+	}
+	lim.tokens = tokens
+	lim.last = now
+}
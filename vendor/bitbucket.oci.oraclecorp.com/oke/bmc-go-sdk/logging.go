@@ -0,0 +1,61 @@
+// Copyright (c) 2017, Oracle and/or its affiliates. All rights reserved.
+
+package baremetal
+
+import "net/http"
+
+// Logger is the subset of *zap.SugaredLogger used by apiRequestor to emit
+// structured request/response logging. A *zap.SugaredLogger satisfies this
+// interface directly; operators who don't use zap can provide their own
+// adapter.
+type Logger interface {
+	Debugw(msg string, keysAndValues ...interface{})
+	Warnw(msg string, keysAndValues ...interface{})
+}
+
+// noopLogger is used when NewClientOptions.Logger is not set, so nothing is
+// logged by default.
+type noopLogger struct{}
+
+func (noopLogger) Debugw(msg string, keysAndValues ...interface{}) {}
+func (noopLogger) Warnw(msg string, keysAndValues ...interface{})  {}
+
+func loggerOrDefault(l Logger) Logger {
+	if l == nil {
+		return noopLogger{}
+	}
+	return l
+}
+
+// sensitiveHeaders lists the request headers that must never be logged
+// verbatim, since they carry bearer credentials for the call.
+var sensitiveHeaders = []string{"Authorization", "x-content-sha256", "opc-obo-token"}
+
+// redactHeaders returns a copy of h with sensitive values replaced, suitable
+// for passing to a logger.
+func redactHeaders(h http.Header) http.Header {
+	redacted := cloneHeader(h)
+	for _, name := range sensitiveHeaders {
+		if redacted.Get(name) != "" {
+			redacted.Set(name, "REDACTED")
+		}
+	}
+	return redacted
+}
+
+// maxLoggedBodyBytes bounds how much of a request/response body is included
+// in debug logs, so a large list response doesn't blow up log volume.
+const maxLoggedBodyBytes = 2048
+
+// truncateForLog renders b as a string for debug logging, truncating it to
+// maxLoggedBodyBytes. Credentials live in headers, not bodies, so unlike
+// redactHeaders this performs no redaction of its own.
+func truncateForLog(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	if len(b) <= maxLoggedBodyBytes {
+		return string(b)
+	}
+	return string(b[:maxLoggedBodyBytes]) + "...(truncated)"
+}
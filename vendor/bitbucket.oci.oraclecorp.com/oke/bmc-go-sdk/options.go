@@ -0,0 +1,47 @@
+// Copyright (c) 2017, Oracle and/or its affiliates. All rights reserved.
+
+package baremetal
+
+import "net/http"
+
+// NewClientOptions configures the behavior of the API clients constructed
+// by the newXxxAPIRequestor family of functions.
+type NewClientOptions struct {
+	Transport   http.RoundTripper
+	UrlTemplate string
+	UserAgent   string
+	Region      string
+
+	// RetryPolicy governs retry behavior for transient failures such as
+	// throttling (HTTP 429) and upstream 5xx errors. Defaults to
+	// defaultRetryPolicy() when nil.
+	RetryPolicy *RetryPolicy
+
+	// DelegationTokenPath, when set, is the path to a projected OKE
+	// delegation token. It is sent as the opc-obo-token header on every
+	// request so that instance-principal-authenticated calls are
+	// authorized on behalf of the invoking OKE user rather than the node.
+	DelegationTokenPath string
+
+	// UseResourcePrincipalAuth, when true, ignores the authInfo passed to
+	// the newXxxAPIRequestor constructors and instead signs every request
+	// with a resourcePrincipalSigner built from the OCI_RESOURCE_PRINCIPAL_*
+	// environment variables, re-reading the session token and key from disk
+	// as they rotate and refreshing ahead of the token's JWT expiry.
+	UseResourcePrincipalAuth bool
+
+	// Logger receives structured request/response logging for every call.
+	// Defaults to a no-op logger when nil. A *zap.SugaredLogger can be
+	// passed directly.
+	Logger Logger
+
+	// Tracer, when set, wraps every call in a TraceSpan so operators can
+	// feed OCI API latency into their tracing backend of choice. Defaults
+	// to a no-op tracer when nil.
+	Tracer Tracer
+
+	// RateLimiter, when set, is consulted before every outbound call.
+	// Defaults to a per-service AIMD-adjusted token bucket sized from
+	// defaultServiceRateLimits when nil.
+	RateLimiter RateLimiter
+}
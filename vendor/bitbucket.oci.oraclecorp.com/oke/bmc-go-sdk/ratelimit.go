@@ -0,0 +1,143 @@
+// Copyright (c) 2017, Oracle and/or its affiliates. All rights reserved.
+
+package baremetal
+
+import (
+	"context"
+	"expvar"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"bitbucket.oci.oraclecorp.com/oke/bmc-go-sdk/internal/rate"
+)
+
+// RateLimiter throttles outbound OCI API calls so a long-lived CCM process
+// doesn't trip tenancy-wide throttles when it manages many Services at
+// once.
+type RateLimiter interface {
+	Wait(ctx context.Context) error
+}
+
+// adaptiveRateLimitFeedback is implemented by the default RateLimiter so
+// apiRequestor can drive its AIMD behavior. Callers supplying their own
+// RateLimiter don't get this feedback loop, only the plain Wait gate.
+type adaptiveRateLimitFeedback interface {
+	OnThrottled()
+	OnSuccess(now time.Time)
+}
+
+// defaultServiceRateLimits are the per-service token bucket ceilings, in
+// requests per second, used when NewClientOptions.RateLimiter is nil. Each
+// newXxxAPIRequestor constructs its own client, so each gets its own bucket.
+var defaultServiceRateLimits = map[string]float64{
+	"core":          20,
+	"loadbalancer":  20,
+	"identity":      20,
+	"objectstorage": 20,
+	"database":      20,
+}
+
+var (
+	rateLimiterCurrentRPS = expvar.NewMap("oci_ratelimiter_current_rps")
+	rateLimiterThrottled  = expvar.NewMap("oci_ratelimiter_throttled_total")
+)
+
+type floatVar float64
+
+func (f floatVar) String() string { return strconv.FormatFloat(float64(f), 'f', 2, 64) }
+
+// adaptiveRateLimiter wraps the internal/rate token-bucket Limiter with an
+// AIMD scheme: a 429 halves the current rate, and the rate additively
+// recovers by +1 rps per minute of sustained success, up to ceiling.
+type adaptiveRateLimiter struct {
+	service string
+	ceiling float64
+
+	limiter *rate.Limiter
+
+	mu          sync.Mutex
+	lastRecover time.Time
+}
+
+func newAdaptiveRateLimiter(service string, ceiling float64) *adaptiveRateLimiter {
+	a := &adaptiveRateLimiter{
+		service:     service,
+		ceiling:     ceiling,
+		limiter:     rate.NewLimiter(rate.Limit(ceiling), int(ceiling)),
+		lastRecover: time.Now(),
+	}
+	rateLimiterCurrentRPS.Set(service, floatVar(ceiling))
+	return a
+}
+
+func (a *adaptiveRateLimiter) Wait(ctx context.Context) error {
+	return a.limiter.Wait(ctx)
+}
+
+// OnThrottled halves the current rate in response to an observed 429.
+func (a *adaptiveRateLimiter) OnThrottled() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	next := float64(a.limiter.Limit()) / 2
+	if next < 1 {
+		next = 1
+	}
+	a.setRateLocked(next)
+	a.lastRecover = time.Now()
+	rateLimiterThrottled.Add(a.service, 1)
+}
+
+// OnSuccess additively recovers the rate by +1 rps, up to ceiling, when at
+// least a full minute has passed since the last recovery or throttle. A
+// single call adds at most 1 rps, however long that interval was.
+func (a *adaptiveRateLimiter) OnSuccess(now time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if now.Sub(a.lastRecover) < time.Minute {
+		return
+	}
+	a.lastRecover = now
+
+	current := float64(a.limiter.Limit())
+	if current >= a.ceiling {
+		return
+	}
+	a.setRateLocked(current + 1)
+}
+
+func (a *adaptiveRateLimiter) setRateLocked(rps float64) {
+	if rps > a.ceiling {
+		rps = a.ceiling
+	}
+	a.limiter.SetLimit(rate.Limit(rps))
+	rateLimiterCurrentRPS.Set(a.service, floatVar(rps))
+}
+
+func rateLimiterOrDefault(r RateLimiter, service string) RateLimiter {
+	if r != nil {
+		return r
+	}
+	ceiling, ok := defaultServiceRateLimits[service]
+	if !ok {
+		ceiling = 20
+	}
+	return newAdaptiveRateLimiter(service, ceiling)
+}
+
+func onRateLimiterResponse(r RateLimiter, resp *http.Response) {
+	feedback, ok := r.(adaptiveRateLimitFeedback)
+	if !ok {
+		return
+	}
+	if resp != nil && resp.StatusCode == http.StatusTooManyRequests {
+		feedback.OnThrottled()
+		return
+	}
+	if resp != nil && resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		feedback.OnSuccess(time.Now())
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright (c) 2017, Oracle and/or its affiliates. All rights reserved.
+
+package baremetal
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestAdaptiveRateLimiterSmoothsBurst(t *testing.T) {
+	lim := newAdaptiveRateLimiter("test-burst", 2)
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := lim.Wait(context.Background()); err != nil {
+			t.Fatalf("Wait(%d) returned error: %v", i, err)
+		}
+	}
+	elapsed := time.Since(start)
+
+	// A burst of 3 calls against a 2 rps limiter (burst size 2) must be
+	// smoothed: the 3rd call has to wait roughly one token interval
+	// (~500ms) rather than firing immediately.
+	if elapsed < 400*time.Millisecond {
+		t.Errorf("3 calls against a 2 rps limiter completed in %v, expected smoothing to take at least ~500ms", elapsed)
+	}
+}
+
+func TestAdaptiveRateLimiterOnThrottledHalvesRate(t *testing.T) {
+	lim := newAdaptiveRateLimiter("test-throttle", 20)
+
+	lim.OnThrottled()
+
+	if got, want := float64(lim.limiter.Limit()), 10.0; got != want {
+		t.Errorf("rate after one throttle = %v, want %v", got, want)
+	}
+
+	lim.OnThrottled()
+	if got, want := float64(lim.limiter.Limit()), 5.0; got != want {
+		t.Errorf("rate after two throttles = %v, want %v", got, want)
+	}
+}
+
+func TestAdaptiveRateLimiterOnThrottledFloorsAtOne(t *testing.T) {
+	lim := newAdaptiveRateLimiter("test-throttle-floor", 1)
+
+	lim.OnThrottled()
+
+	if got, want := float64(lim.limiter.Limit()), 1.0; got != want {
+		t.Errorf("rate floored at = %v, want %v", got, want)
+	}
+}
+
+// fakeClock lets the recovery test advance time deterministically instead
+// of sleeping for real minutes.
+type fakeClock struct {
+	now time.Time
+}
+
+func (f *fakeClock) Now() time.Time { return f.now }
+func (f *fakeClock) Advance(d time.Duration) time.Time {
+	f.now = f.now.Add(d)
+	return f.now
+}
+
+func TestAdaptiveRateLimiterOnSuccessRecoversOverTime(t *testing.T) {
+	lim := newAdaptiveRateLimiter("test-recover", 10)
+	lim.OnThrottled() // 10 -> 5
+
+	clock := &fakeClock{now: time.Now()}
+	lim.lastRecover = clock.Now()
+
+	// Less than a minute since the throttle: no recovery yet.
+	if err := callOnSuccess(lim, clock.Advance(30*time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := float64(lim.limiter.Limit()), 5.0; got != want {
+		t.Errorf("rate after 30s = %v, want %v (no recovery expected yet)", got, want)
+	}
+
+	// A full minute passes: rate recovers by +1 rps.
+	if err := callOnSuccess(lim, clock.Advance(31*time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := float64(lim.limiter.Limit()), 6.0; got != want {
+		t.Errorf("rate after 61s = %v, want %v", got, want)
+	}
+}
+
+func TestAdaptiveRateLimiterOnSuccessDoesNotExceedCeiling(t *testing.T) {
+	lim := newAdaptiveRateLimiter("test-ceiling", 5)
+
+	lim.lastRecover = time.Now().Add(-2 * time.Minute)
+	lim.OnSuccess(time.Now())
+
+	if got, want := float64(lim.limiter.Limit()), 5.0; got != want {
+		t.Errorf("rate exceeded ceiling: got %v, want %v", got, want)
+	}
+}
+
+func callOnSuccess(lim *adaptiveRateLimiter, now time.Time) error {
+	lim.OnSuccess(now)
+	return nil
+}
+
+func TestOnRateLimiterResponseDrivesFeedback(t *testing.T) {
+	lim := newAdaptiveRateLimiter("test-feedback", 10)
+
+	onRateLimiterResponse(lim, &http.Response{StatusCode: http.StatusTooManyRequests})
+	if got, want := float64(lim.limiter.Limit()), 5.0; got != want {
+		t.Errorf("rate after 429 = %v, want %v", got, want)
+	}
+
+	// A custom RateLimiter that doesn't implement adaptiveRateLimitFeedback
+	// must be left alone.
+	onRateLimiterResponse(staticRateLimiter{}, &http.Response{StatusCode: http.StatusTooManyRequests})
+}
+
+type staticRateLimiter struct{}
+
+func (staticRateLimiter) Wait(ctx context.Context) error { return nil }
+
+func TestRateLimiterOrDefaultUsesPerServiceCeiling(t *testing.T) {
+	r := rateLimiterOrDefault(nil, "identity")
+	adaptive, ok := r.(*adaptiveRateLimiter)
+	if !ok {
+		t.Fatalf("expected *adaptiveRateLimiter, got %T", r)
+	}
+	if got, want := float64(adaptive.limiter.Limit()), defaultServiceRateLimits["identity"]; got != want {
+		t.Errorf("default ceiling for identity = %v, want %v", got, want)
+	}
+
+	custom := staticRateLimiter{}
+	if got := rateLimiterOrDefault(custom, "identity"); got != custom {
+		t.Errorf("rateLimiterOrDefault should return the supplied limiter unchanged, got %#v", got)
+	}
+}
@@ -4,11 +4,12 @@ package baremetal
 
 import (
 	"bytes"
+	"context"
+	"fmt"
 	"io"
-	"log"
+	"io/ioutil"
 	"net/http"
-	"net/http/httputil"
-	"os"
+	"time"
 )
 
 type requestor interface {
@@ -18,77 +19,172 @@ type requestor interface {
 }
 
 type apiRequestor struct {
-	httpClient  *http.Client
-	authInfo    *authenticationInfo
-	urlBuilder  urlBuilderFn
-	urlTemplate string
-	userAgent   string
-	region      string
+	httpClient        *http.Client
+	authInfo          *authenticationInfo
+	resourcePrincipal *resourcePrincipalSigner
+	urlBuilder        urlBuilderFn
+	urlTemplate       string
+	userAgent         string
+	region            string
+	service           string
+	retryPolicy       *RetryPolicy
+	delegationToken   *delegationTokenSource
+	logger            Logger
+	tracer            Tracer
+	rateLimiter       RateLimiter
 }
 
-func newCoreAPIRequestor(authInfo *authenticationInfo, nco *NewClientOptions) (r *apiRequestor) {
+// authInfoForRequest returns the authenticationInfo to sign the next request
+// with. When UseResourcePrincipalAuth was set, this re-reads the session
+// token and key from disk if either has rotated, or if the cached token is
+// close to its JWT expiry, so every request picks up a live signer the same
+// way api.delegationToken.Token() picks up a live delegation token.
+func (api *apiRequestor) authInfoForRequest() (*authenticationInfo, error) {
+	if api.resourcePrincipal != nil {
+		return api.resourcePrincipal.authenticationInfo()
+	}
+	return api.authInfo, nil
+}
+
+func newCoreAPIRequestor(authInfo *authenticationInfo, nco *NewClientOptions) (r *apiRequestor, e error) {
+	resourcePrincipal, e := resourcePrincipalSignerOrNil(nco.UseResourcePrincipalAuth)
+	if e != nil {
+		return nil, e
+	}
 	return &apiRequestor{
 		httpClient: &http.Client{
 			Transport: nco.Transport,
 		},
-		authInfo:    authInfo,
-		urlBuilder:  buildCoreURL,
-		urlTemplate: nco.UrlTemplate,
-		userAgent:   nco.UserAgent,
-		region:      nco.Region,
-	}
+		authInfo:          authInfo,
+		resourcePrincipal: resourcePrincipal,
+		urlBuilder:        buildCoreURL,
+		urlTemplate:       nco.UrlTemplate,
+		userAgent:         nco.UserAgent,
+		region:            nco.Region,
+		service:           "core",
+		retryPolicy:       retryPolicyOrDefault(nco.RetryPolicy),
+		delegationToken:   delegationTokenSourceOrNil(nco.DelegationTokenPath),
+		logger:            loggerOrDefault(nco.Logger),
+		tracer:            tracerOrDefault(nco.Tracer),
+		rateLimiter:       rateLimiterOrDefault(nco.RateLimiter, "core"),
+	}, nil
 }
 
-func newObjectStorageAPIRequestor(authInfo *authenticationInfo, nco *NewClientOptions) (r *apiRequestor) {
+func newObjectStorageAPIRequestor(authInfo *authenticationInfo, nco *NewClientOptions) (r *apiRequestor, e error) {
+	resourcePrincipal, e := resourcePrincipalSignerOrNil(nco.UseResourcePrincipalAuth)
+	if e != nil {
+		return nil, e
+	}
 	return &apiRequestor{
 		httpClient: &http.Client{
 			Transport: nco.Transport,
 		},
-		authInfo:    authInfo,
-		urlBuilder:  buildObjectStorageURL,
-		urlTemplate: nco.UrlTemplate,
-		userAgent:   nco.UserAgent,
-		region:      nco.Region,
-	}
+		authInfo:          authInfo,
+		resourcePrincipal: resourcePrincipal,
+		urlBuilder:        buildObjectStorageURL,
+		urlTemplate:       nco.UrlTemplate,
+		userAgent:         nco.UserAgent,
+		region:            nco.Region,
+		service:           "objectstorage",
+		retryPolicy:       retryPolicyOrDefault(nco.RetryPolicy),
+		delegationToken:   delegationTokenSourceOrNil(nco.DelegationTokenPath),
+		logger:            loggerOrDefault(nco.Logger),
+		tracer:            tracerOrDefault(nco.Tracer),
+		rateLimiter:       rateLimiterOrDefault(nco.RateLimiter, "objectstorage"),
+	}, nil
 }
 
-func newDatabaseAPIRequestor(authInfo *authenticationInfo, nco *NewClientOptions) (r *apiRequestor) {
+func newDatabaseAPIRequestor(authInfo *authenticationInfo, nco *NewClientOptions) (r *apiRequestor, e error) {
+	resourcePrincipal, e := resourcePrincipalSignerOrNil(nco.UseResourcePrincipalAuth)
+	if e != nil {
+		return nil, e
+	}
 	return &apiRequestor{
 		httpClient: &http.Client{
 			Transport: nco.Transport,
 		},
-		authInfo:    authInfo,
-		urlBuilder:  buildDatabaseURL,
-		urlTemplate: nco.UrlTemplate,
-		userAgent:   nco.UserAgent,
-		region:      nco.Region,
-	}
+		authInfo:          authInfo,
+		resourcePrincipal: resourcePrincipal,
+		urlBuilder:        buildDatabaseURL,
+		urlTemplate:       nco.UrlTemplate,
+		userAgent:         nco.UserAgent,
+		region:            nco.Region,
+		service:           "database",
+		retryPolicy:       retryPolicyOrDefault(nco.RetryPolicy),
+		delegationToken:   delegationTokenSourceOrNil(nco.DelegationTokenPath),
+		logger:            loggerOrDefault(nco.Logger),
+		tracer:            tracerOrDefault(nco.Tracer),
+		rateLimiter:       rateLimiterOrDefault(nco.RateLimiter, "database"),
+	}, nil
 }
 
-func newIdentityAPIRequestor(authInfo *authenticationInfo, nco *NewClientOptions) (r *apiRequestor) {
+func newIdentityAPIRequestor(authInfo *authenticationInfo, nco *NewClientOptions) (r *apiRequestor, e error) {
+	resourcePrincipal, e := resourcePrincipalSignerOrNil(nco.UseResourcePrincipalAuth)
+	if e != nil {
+		return nil, e
+	}
 	return &apiRequestor{
 		httpClient: &http.Client{
 			Transport: nco.Transport,
 		},
-		authInfo:    authInfo,
-		urlBuilder:  buildIdentityURL,
-		urlTemplate: nco.UrlTemplate,
-		userAgent:   nco.UserAgent,
-		region:      nco.Region,
-	}
+		authInfo:          authInfo,
+		resourcePrincipal: resourcePrincipal,
+		urlBuilder:        buildIdentityURL,
+		urlTemplate:       nco.UrlTemplate,
+		userAgent:         nco.UserAgent,
+		region:            nco.Region,
+		service:           "identity",
+		retryPolicy:       retryPolicyOrDefault(nco.RetryPolicy),
+		delegationToken:   delegationTokenSourceOrNil(nco.DelegationTokenPath),
+		logger:            loggerOrDefault(nco.Logger),
+		tracer:            tracerOrDefault(nco.Tracer),
+		rateLimiter:       rateLimiterOrDefault(nco.RateLimiter, "identity"),
+	}, nil
 }
 
-func newLoadBalancerAPIRequestor(authInfo *authenticationInfo, nco *NewClientOptions) (r *apiRequestor) {
+func newLoadBalancerAPIRequestor(authInfo *authenticationInfo, nco *NewClientOptions) (r *apiRequestor, e error) {
+	resourcePrincipal, e := resourcePrincipalSignerOrNil(nco.UseResourcePrincipalAuth)
+	if e != nil {
+		return nil, e
+	}
 	return &apiRequestor{
 		httpClient: &http.Client{
 			Transport: nco.Transport,
 		},
-		authInfo:    authInfo,
-		urlBuilder:  buildLoadBalancerURL,
-		urlTemplate: nco.UrlTemplate,
-		userAgent:   nco.UserAgent,
-		region:      nco.Region,
+		authInfo:          authInfo,
+		resourcePrincipal: resourcePrincipal,
+		urlBuilder:        buildLoadBalancerURL,
+		urlTemplate:       nco.UrlTemplate,
+		userAgent:         nco.UserAgent,
+		region:            nco.Region,
+		service:           "loadbalancer",
+		retryPolicy:       retryPolicyOrDefault(nco.RetryPolicy),
+		delegationToken:   delegationTokenSourceOrNil(nco.DelegationTokenPath),
+		logger:            loggerOrDefault(nco.Logger),
+		tracer:            tracerOrDefault(nco.Tracer),
+		rateLimiter:       rateLimiterOrDefault(nco.RateLimiter, "loadbalancer"),
+	}, nil
+}
+
+func retryPolicyOrDefault(p *RetryPolicy) *RetryPolicy {
+	if p == nil {
+		return defaultRetryPolicy()
 	}
+	return p
+}
+
+func delegationTokenSourceOrNil(path string) *delegationTokenSource {
+	if path == "" {
+		return nil
+	}
+	return newDelegationTokenSource(path)
+}
+
+func resourcePrincipalSignerOrNil(enabled bool) (*resourcePrincipalSigner, error) {
+	if !enabled {
+		return nil, nil
+	}
+	return newResourcePrincipalSigner()
 }
 
 func (api *apiRequestor) deleteRequest(reqOpts request) (e error) {
@@ -104,10 +200,20 @@ func (api *apiRequestor) getRequest(reqOpts request) (getResp *response, e error
 }
 
 func (api *apiRequestor) request(method string, reqOpts request) (r *response, e error) {
+	return api.requestContext(context.Background(), method, reqOpts)
+}
+
+// requestContext is identical to request but allows callers to bound or
+// cancel the (possibly retried) call via ctx.
+func (api *apiRequestor) requestContext(ctx context.Context, method string, reqOpts request) (r *response, e error) {
+	ctx, span := api.tracer.Start(ctx, api.service+"."+method)
+	defer span.End()
+
 	var url string
 	if url, e = reqOpts.marshalURL(api.urlTemplate, api.region, api.urlBuilder); e != nil {
 		return
 	}
+	span.SetAttribute("url", url)
 
 	var body interface{}
 	if method != http.MethodDelete && method != http.MethodGet {
@@ -115,54 +221,141 @@ func (api *apiRequestor) request(method string, reqOpts request) (r *response, e
 			return
 		}
 	}
-	var bodyReader io.Reader
+
+	// Buffer the body up front so it can be replayed across retry attempts:
+	// an io.ReadSeeker is rewound, everything else is already a []byte.
+	var bodyBytes []byte
+	var bodySeeker io.ReadSeeker
 	switch bodyValue := body.(type) {
 	case io.ReadSeeker:
-		bodyReader = bodyValue
+		bodySeeker = bodyValue
 	case []byte:
-		bodyReader = bytes.NewBuffer(bodyValue)
-	default:
-		bodyReader = bytes.NewBuffer([]byte{})
+		bodyBytes = bodyValue
 	}
 
-	var req *http.Request
-	if req, e = http.NewRequest(method, url, bodyReader); e != nil {
-		return
-	}
-	req.Header = reqOpts.marshalHeader()
+	header := reqOpts.marshalHeader()
 
-	if e = createAuthorizationHeader(req, api.authInfo, api.userAgent, body); e != nil {
-		return
-	}
-	if e != nil {
-		log.Printf("[WARN] Could not get HTTP authorization header, error: %#v\n", e)
-		return
+	policy := api.retryPolicy
+	if policy == nil {
+		policy = defaultRetryPolicy()
 	}
 
-	if os.Getenv("DEBUG") != "" {
-		reqdump, err := httputil.DumpRequestOut(req, true)
-		if err == nil {
-			log.Printf("[DEBUG] HTTP Request: %v\n", string(reqdump))
+	var resp *http.Response
+	for attempt := 0; attempt < policy.MaxAttempts; attempt++ {
+		var bodyReader io.Reader
+		switch {
+		case bodySeeker != nil:
+			if _, e = bodySeeker.Seek(0, io.SeekStart); e != nil {
+				return
+			}
+			bodyReader = bodySeeker
+		case bodyBytes != nil:
+			bodyReader = bytes.NewReader(bodyBytes)
+		default:
+			bodyReader = bytes.NewReader([]byte{})
+		}
+
+		attemptCtx := ctx
+		var cancel context.CancelFunc
+		if policy.PerAttemptTimeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, policy.PerAttemptTimeout)
+		}
+
+		var req *http.Request
+		if req, e = http.NewRequest(method, url, bodyReader); e != nil {
+			if cancel != nil {
+				cancel()
+			}
+			return
+		}
+		req = req.WithContext(attemptCtx)
+		req.Header = cloneHeader(header)
+
+		authInfo, authErr := api.authInfoForRequest()
+		if authErr != nil {
+			e = authErr
+			api.logger.Warnw("could not resolve authentication info", "error", e, "service", api.service, "method", method, "url", url)
+			if cancel != nil {
+				cancel()
+			}
+			return
+		}
+
+		if e = createAuthorizationHeader(req, authInfo, api.userAgent, body); e != nil {
+			api.logger.Warnw("could not build authorization header", "error", e, "service", api.service, "method", method, "url", url)
+			if cancel != nil {
+				cancel()
+			}
+			return
+		}
+
+		if api.delegationToken != nil {
+			var token string
+			if token, e = api.delegationToken.Token(); e != nil {
+				api.logger.Warnw("could not read delegation token", "error", e, "service", api.service)
+				if cancel != nil {
+					cancel()
+				}
+				return
+			}
+			req.Header.Set("opc-obo-token", token)
+		}
+
+		if e = api.rateLimiter.Wait(ctx); e != nil {
+			if cancel != nil {
+				cancel()
+			}
+			return
+		}
+
+		api.logger.Debugw("oci api request", "method", method, "url", url, "region", api.region, "service", api.service,
+			"attempt", attempt+1, "opc-request-id", req.Header.Get("opc-request-id"), "headers", redactHeaders(req.Header),
+			"body", truncateForLog(bodyBytes))
+
+		start := time.Now()
+		resp, e = api.httpClient.Do(req)
+		duration := time.Since(start)
+		if cancel != nil {
+			if e != nil && attemptCtx.Err() == context.DeadlineExceeded {
+				e = fmt.Errorf("attempt %d: %w", attempt+1, attemptCtx.Err())
+			}
+			cancel()
+		}
+
+		status := 0
+		if resp != nil {
+			status = resp.StatusCode
+		}
+		api.logger.Debugw("oci api response", "method", method, "url", url, "region", api.region, "service", api.service,
+			"attempt", attempt+1, "opc-request-id", req.Header.Get("opc-request-id"), "status", status, "duration_ms", duration.Milliseconds(), "error", e)
+
+		onRateLimiterResponse(api.rateLimiter, resp)
+
+		retry, sleep := policy.nextAction(attempt, resp, e)
+		if !retry {
+			break
+		}
+
+		if e != nil {
+			api.logger.Warnw("oci api request failed, retrying", "error", e, "attempt", attempt+1, "maxAttempts", policy.MaxAttempts, "service", api.service)
 		} else {
-			log.Printf("[WARN] Could not dump HTTP Request: %#v, error: %#v\n", req, err)
+			api.logger.Warnw("oci api request throttled or failed, retrying", "status", resp.StatusCode, "attempt", attempt+1, "maxAttempts", policy.MaxAttempts, "service", api.service)
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
 		}
-	}
 
-	var resp *http.Response
-	resp, e = api.httpClient.Do(req)
+		select {
+		case <-time.After(sleep):
+		case <-ctx.Done():
+			e = ctx.Err()
+			return
+		}
+	}
 	if e != nil {
-		log.Printf("[WARN] Could not get HTTP Response, error: %#v\n", e)
 		return
 	}
 
-	if os.Getenv("DEBUG") != "" {
-		respdump, err := httputil.DumpResponse(resp, true)
-		if err == nil {
-			log.Printf("[DEBUG] HTTP Response: %v\n", string(respdump))
-		} else {
-			log.Printf("[WARN] Could not dump HTTP Response: %#v, error: %#v\n", resp, err)
-		}
-	}
+	span.SetAttribute("status", resp.StatusCode)
 
 	var reader bytes.Buffer
 	isErrorResponse := resp.StatusCode < 200 || resp.StatusCode >= 300
@@ -173,6 +366,14 @@ func (api *apiRequestor) request(method string, reqOpts request) (r *response, e
 		if e != nil {
 			return
 		}
+		api.logger.Debugw("oci api response body", "method", method, "url", url, "service", api.service,
+			"status", resp.StatusCode, "body", truncateForLog(reader.Bytes()))
+	} else {
+		// returnRespBodyAsStream callers (e.g. object storage GetObject) get
+		// the raw resp.Body back to stream; buffering it here just to log it
+		// would defeat the point, so it's never logged.
+		api.logger.Debugw("oci api response body", "method", method, "url", url, "service", api.service,
+			"status", resp.StatusCode, "body", "<streamed, not logged>")
 	}
 
 	// we still have to check response code, if we get non 200 response
@@ -201,3 +402,13 @@ func (api *apiRequestor) request(method string, reqOpts request) (r *response, e
 
 	return
 }
+
+func cloneHeader(h http.Header) http.Header {
+	clone := make(http.Header, len(h))
+	for k, v := range h {
+		vv := make([]string, len(v))
+		copy(vv, v)
+		clone[k] = vv
+	}
+	return clone
+}
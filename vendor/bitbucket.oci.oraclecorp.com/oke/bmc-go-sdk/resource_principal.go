@@ -0,0 +1,202 @@
+// Copyright (c) 2017, Oracle and/or its affiliates. All rights reserved.
+
+package baremetal
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	envResourcePrincipalVersion    = "OCI_RESOURCE_PRINCIPAL_VERSION"
+	envResourcePrincipalRPST       = "OCI_RESOURCE_PRINCIPAL_RPST"
+	envResourcePrincipalPrivatePEM = "OCI_RESOURCE_PRINCIPAL_PRIVATE_PEM"
+	envResourcePrincipalPassphrase = "OCI_RESOURCE_PRINCIPAL_PRIVATE_PEM_PASSPHRASE"
+	envResourcePrincipalRegion     = "OCI_RESOURCE_PRINCIPAL_REGION"
+
+	supportedResourcePrincipalVersion = "2.2"
+
+	// refreshSkew is how far ahead of the RPST's JWT expiry we proactively
+	// refresh, so a signing attempt never races an about-to-expire token.
+	refreshSkew = time.Minute
+)
+
+// authenticationInfo holds the credentials used by createAuthorizationHeader
+// to sign an outbound OCI API call.
+type authenticationInfo struct {
+	keyID      string
+	privateKey *rsa.PrivateKey
+}
+
+// resourcePrincipalSigner produces the authenticationInfo for the OCI
+// Resource Principal Session Token (RPST) flow used by OCI Functions and
+// OKE workloads. OCI_RESOURCE_PRINCIPAL_RPST and OCI_RESOURCE_PRINCIPAL_PRIVATE_PEM
+// name files that the platform rewrites in place as the session is
+// refreshed, so the signer re-reads them whenever their mtime changes, or
+// proactively ahead of the cached RPST's JWT expiry.
+type resourcePrincipalSigner struct {
+	rpstPath   string
+	keyPath    string
+	passphrase []byte
+	region     string
+
+	mu      sync.Mutex
+	rpstMod time.Time
+	keyMod  time.Time
+	expiry  time.Time
+	current *authenticationInfo
+}
+
+// newResourcePrincipalSigner reads the OCI_RESOURCE_PRINCIPAL_* environment
+// variables and returns a signer for the resource principal flow, having
+// validated that an authenticationInfo can be built from them at least once.
+func newResourcePrincipalSigner() (*resourcePrincipalSigner, error) {
+	if v := os.Getenv(envResourcePrincipalVersion); v != supportedResourcePrincipalVersion {
+		return nil, fmt.Errorf("unsupported %s %q, expected %q", envResourcePrincipalVersion, v, supportedResourcePrincipalVersion)
+	}
+
+	rpstPath := os.Getenv(envResourcePrincipalRPST)
+	keyPath := os.Getenv(envResourcePrincipalPrivatePEM)
+	region := os.Getenv(envResourcePrincipalRegion)
+	if rpstPath == "" || keyPath == "" || region == "" {
+		return nil, fmt.Errorf("%s, %s and %s must all be set", envResourcePrincipalRPST, envResourcePrincipalPrivatePEM, envResourcePrincipalRegion)
+	}
+
+	s := &resourcePrincipalSigner{
+		rpstPath:   rpstPath,
+		keyPath:    keyPath,
+		passphrase: []byte(os.Getenv(envResourcePrincipalPassphrase)),
+		region:     region,
+	}
+	if _, err := s.authenticationInfo(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// authenticationInfo returns the current signing credentials, reloading the
+// RPST and/or private key from disk if either file has changed since the
+// last read, or if the cached RPST is within refreshSkew of expiring.
+func (s *resourcePrincipalSigner) authenticationInfo() (*authenticationInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rpstStat, err := os.Stat(s.rpstPath)
+	if err != nil {
+		return nil, err
+	}
+	keyStat, err := os.Stat(s.keyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	stale := s.current == nil ||
+		!rpstStat.ModTime().Equal(s.rpstMod) ||
+		!keyStat.ModTime().Equal(s.keyMod) ||
+		time.Now().Add(refreshSkew).After(s.expiry)
+
+	if !stale {
+		return s.current, nil
+	}
+
+	rpstBytes, err := ioutil.ReadFile(s.rpstPath)
+	if err != nil {
+		return nil, err
+	}
+	rpst := strings.TrimSpace(string(rpstBytes))
+
+	expiry, err := jwtExpiry(rpst)
+	if err != nil {
+		return nil, fmt.Errorf("parsing resource principal session token: %v", err)
+	}
+
+	keyBytes, err := ioutil.ReadFile(s.keyPath)
+	if err != nil {
+		return nil, err
+	}
+	privateKey, err := parsePrivateKeyPEM(keyBytes, s.passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("parsing resource principal private key: %v", err)
+	}
+
+	s.current = &authenticationInfo{
+		keyID:      "ST$" + rpst,
+		privateKey: privateKey,
+	}
+	s.rpstMod = rpstStat.ModTime()
+	s.keyMod = keyStat.ModTime()
+	s.expiry = expiry
+
+	return s.current, nil
+}
+
+// jwtExpiry extracts the "exp" claim from a JWT without verifying its
+// signature; signature verification is OCI's responsibility on the
+// receiving end, we only need to know when to refresh.
+func jwtExpiry(token string) (time.Time, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, errors.New("not a well-formed JWT")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var claims struct {
+		Expiry int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return time.Time{}, err
+	}
+	if claims.Expiry == 0 {
+		return time.Time{}, errors.New("missing exp claim")
+	}
+
+	return time.Unix(claims.Expiry, 0), nil
+}
+
+// parsePrivateKeyPEM decodes a PEM-encoded RSA private key, optionally
+// encrypted with passphrase.
+func parsePrivateKeyPEM(pemBytes, passphrase []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	der := block.Bytes
+	if x509.IsEncryptedPEMBlock(block) {
+		if len(passphrase) == 0 {
+			return nil, errors.New("private key is encrypted but no passphrase was provided")
+		}
+		var err error
+		if der, err = x509.DecryptPEMBlock(block, passphrase); err != nil {
+			return nil, err
+		}
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("private key is not an RSA key")
+	}
+	return rsaKey, nil
+}
@@ -0,0 +1,327 @@
+// Copyright (c) 2017, Oracle and/or its affiliates. All rights reserved.
+
+package baremetal
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func fakeJWT(t *testing.T, exp int64) string {
+	t.Helper()
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`))
+	payload, err := json.Marshal(struct {
+		Expiry int64 `json:"exp"`
+	}{exp})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := base64.RawURLEncoding.EncodeToString(payload)
+	return header + "." + body + ".sig"
+}
+
+func sameRSAKey(a, b *rsa.PrivateKey) bool {
+	return a.D.Cmp(b.D) == 0 && a.N.Cmp(b.N) == 0
+}
+
+func TestJwtExpiry(t *testing.T) {
+	want := time.Unix(1893456000, 0)
+	got, err := jwtExpiry(fakeJWT(t, want.Unix()))
+	if err != nil {
+		t.Fatalf("jwtExpiry: %v", err)
+	}
+	if !got.Equal(want) {
+		t.Errorf("jwtExpiry() = %v, want %v", got, want)
+	}
+}
+
+func TestJwtExpiryMalformed(t *testing.T) {
+	if _, err := jwtExpiry("not-a-jwt"); err == nil {
+		t.Error("expected an error for a malformed token")
+	}
+}
+
+func TestJwtExpiryMissingExpClaim(t *testing.T) {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`))
+	payload := base64.RawURLEncoding.EncodeToString([]byte(`{}`))
+	if _, err := jwtExpiry(header + "." + payload + ".sig"); err == nil {
+		t.Error("expected an error for a token with no exp claim")
+	}
+}
+
+func TestParsePrivateKeyPEMPKCS1(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der := x509.MarshalPKCS1PrivateKey(key)
+	pemBytes := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: der})
+
+	got, err := parsePrivateKeyPEM(pemBytes, nil)
+	if err != nil {
+		t.Fatalf("parsePrivateKeyPEM: %v", err)
+	}
+	if !sameRSAKey(got, key) {
+		t.Error("parsed key does not match the original PKCS1 key")
+	}
+}
+
+func TestParsePrivateKeyPEMPKCS8(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pemBytes := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+
+	got, err := parsePrivateKeyPEM(pemBytes, nil)
+	if err != nil {
+		t.Fatalf("parsePrivateKeyPEM: %v", err)
+	}
+	if !sameRSAKey(got, key) {
+		t.Error("parsed key does not match the original PKCS8 key")
+	}
+}
+
+func TestParsePrivateKeyPEMEncrypted(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der := x509.MarshalPKCS1PrivateKey(key)
+	passphrase := []byte("correct horse battery staple")
+	block, err := x509.EncryptPEMBlock(rand.Reader, "RSA PRIVATE KEY", der, passphrase, x509.PEMCipherAES256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pemBytes := pem.EncodeToMemory(block)
+
+	got, err := parsePrivateKeyPEM(pemBytes, passphrase)
+	if err != nil {
+		t.Fatalf("parsePrivateKeyPEM: %v", err)
+	}
+	if !sameRSAKey(got, key) {
+		t.Error("parsed key does not match the original encrypted key")
+	}
+
+	if _, err := parsePrivateKeyPEM(pemBytes, nil); err == nil {
+		t.Error("expected an error when decrypting without a passphrase")
+	}
+}
+
+func TestParsePrivateKeyPEMInvalid(t *testing.T) {
+	if _, err := parsePrivateKeyPEM([]byte("not a pem file"), nil); err == nil {
+		t.Error("expected an error for non-PEM input")
+	}
+}
+
+// writeKeyPair writes a PKCS1-PEM-encoded RSA key and a JWT RPST expiring at
+// exp under dir, returning their paths.
+func writeKeyPair(t *testing.T, dir string, exp time.Time) (rpstPath, keyPath string) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der := x509.MarshalPKCS1PrivateKey(key)
+	pemBytes := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: der})
+
+	keyPath = filepath.Join(dir, "key.pem")
+	if err := ioutil.WriteFile(keyPath, pemBytes, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	rpstPath = filepath.Join(dir, "rpst")
+	if err := ioutil.WriteFile(rpstPath, []byte(fakeJWT(t, exp.Unix())), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return rpstPath, keyPath
+}
+
+// setenvForTest sets key=value and returns a func that restores the
+// environment variable's prior state; callers defer the returned func.
+func setenvForTest(t *testing.T, key, value string) func() {
+	t.Helper()
+	old, had := os.LookupEnv(key)
+	if err := os.Setenv(key, value); err != nil {
+		t.Fatal(err)
+	}
+	return func() {
+		if had {
+			os.Setenv(key, old)
+		} else {
+			os.Unsetenv(key)
+		}
+	}
+}
+
+func TestResourcePrincipalSignerRotatesOnMtimeChange(t *testing.T) {
+	dir, err := ioutil.TempDir("", "resource-principal-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	rpstPath, keyPath := writeKeyPair(t, dir, time.Now().Add(time.Hour))
+
+	s := &resourcePrincipalSigner{rpstPath: rpstPath, keyPath: keyPath, region: "us-phoenix-1"}
+
+	first, err := s.authenticationInfo()
+	if err != nil {
+		t.Fatalf("authenticationInfo: %v", err)
+	}
+
+	// Re-reading without any change on disk must return the same cached
+	// authenticationInfo rather than reparsing.
+	second, err := s.authenticationInfo()
+	if err != nil {
+		t.Fatalf("authenticationInfo: %v", err)
+	}
+	if second != first {
+		t.Error("authenticationInfo() reparsed even though nothing on disk changed")
+	}
+
+	// Kubernetes rewrites the projected RPST file in place on rotation;
+	// simulate that by writing a new token with a later mtime.
+	time.Sleep(10 * time.Millisecond)
+	newRPST := fakeJWT(t, time.Now().Add(2*time.Hour).Unix())
+	if err := ioutil.WriteFile(rpstPath, []byte(newRPST), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	third, err := s.authenticationInfo()
+	if err != nil {
+		t.Fatalf("authenticationInfo: %v", err)
+	}
+	if third == first {
+		t.Error("authenticationInfo() did not reparse after the RPST file's mtime changed")
+	}
+	if third.keyID != "ST$"+newRPST {
+		t.Errorf("keyID = %q, want ST$ prefix over the rotated RPST", third.keyID)
+	}
+}
+
+func TestResourcePrincipalSignerRotatesOnKeyChange(t *testing.T) {
+	dir, err := ioutil.TempDir("", "resource-principal-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	rpstPath, keyPath := writeKeyPair(t, dir, time.Now().Add(time.Hour))
+
+	s := &resourcePrincipalSigner{rpstPath: rpstPath, keyPath: keyPath, region: "us-phoenix-1"}
+	first, err := s.authenticationInfo()
+	if err != nil {
+		t.Fatalf("authenticationInfo: %v", err)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	newKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pemBytes := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(newKey)})
+	if err := ioutil.WriteFile(keyPath, pemBytes, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := s.authenticationInfo()
+	if err != nil {
+		t.Fatalf("authenticationInfo: %v", err)
+	}
+	if second == first {
+		t.Error("authenticationInfo() did not reparse after the private key file's mtime changed")
+	}
+	if !sameRSAKey(second.privateKey, newKey) {
+		t.Error("authenticationInfo() did not pick up the rotated private key")
+	}
+}
+
+func TestNewResourcePrincipalSignerMissingEnv(t *testing.T) {
+	for _, key := range []string{
+		envResourcePrincipalVersion,
+		envResourcePrincipalRPST,
+		envResourcePrincipalPrivatePEM,
+		envResourcePrincipalRegion,
+	} {
+		old, had := os.LookupEnv(key)
+		os.Unsetenv(key)
+		if had {
+			defer os.Setenv(key, old)
+		}
+	}
+
+	if _, err := newResourcePrincipalSigner(); err == nil {
+		t.Error("expected an error when no OCI_RESOURCE_PRINCIPAL_* env vars are set")
+	}
+}
+
+func TestNewResourcePrincipalSignerFromEnv(t *testing.T) {
+	dir, err := ioutil.TempDir("", "resource-principal-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	rpstPath, keyPath := writeKeyPair(t, dir, time.Now().Add(time.Hour))
+
+	defer setenvForTest(t, envResourcePrincipalVersion, supportedResourcePrincipalVersion)()
+	defer setenvForTest(t, envResourcePrincipalRPST, rpstPath)()
+	defer setenvForTest(t, envResourcePrincipalPrivatePEM, keyPath)()
+	defer setenvForTest(t, envResourcePrincipalRegion, "us-phoenix-1")()
+	os.Unsetenv(envResourcePrincipalPassphrase)
+
+	s, err := newResourcePrincipalSigner()
+	if err != nil {
+		t.Fatalf("newResourcePrincipalSigner: %v", err)
+	}
+	if _, err := s.authenticationInfo(); err != nil {
+		t.Fatalf("authenticationInfo: %v", err)
+	}
+}
+
+func TestResourcePrincipalSignerWiredIntoRequestor(t *testing.T) {
+	dir, err := ioutil.TempDir("", "resource-principal-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	rpstPath, keyPath := writeKeyPair(t, dir, time.Now().Add(time.Hour))
+
+	defer setenvForTest(t, envResourcePrincipalVersion, supportedResourcePrincipalVersion)()
+	defer setenvForTest(t, envResourcePrincipalRPST, rpstPath)()
+	defer setenvForTest(t, envResourcePrincipalPrivatePEM, keyPath)()
+	defer setenvForTest(t, envResourcePrincipalRegion, "us-phoenix-1")()
+	os.Unsetenv(envResourcePrincipalPassphrase)
+
+	api, err := newCoreAPIRequestor(nil, &NewClientOptions{UseResourcePrincipalAuth: true})
+	if err != nil {
+		t.Fatalf("newCoreAPIRequestor: %v", err)
+	}
+	if api.resourcePrincipal == nil {
+		t.Fatal("expected resourcePrincipal to be set when UseResourcePrincipalAuth is true")
+	}
+
+	info, err := api.authInfoForRequest()
+	if err != nil {
+		t.Fatalf("authInfoForRequest: %v", err)
+	}
+	if info == nil || info.privateKey == nil {
+		t.Error("authInfoForRequest() returned no usable authenticationInfo")
+	}
+}
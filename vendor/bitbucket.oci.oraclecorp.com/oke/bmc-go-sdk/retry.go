@@ -0,0 +1,109 @@
+// Copyright (c) 2017, Oracle and/or its affiliates. All rights reserved.
+
+package baremetal
+
+import (
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls whether and how apiRequestor.request retries a
+// failed call. The zero value is not usable; use defaultRetryPolicy() or
+// construct one with sensible values for every field.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts (including the first)
+	// that will be made for a single request.
+	MaxAttempts int
+
+	// BaseDelay and MaxDelay bound the exponential backoff applied between
+	// attempts: sleep = min(MaxDelay, BaseDelay * 2^attempt), then full
+	// jitter is applied on top.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+
+	// ShouldRetry decides whether a given response/error pair warrants
+	// another attempt. resp is nil if the error came from the transport
+	// rather than the server.
+	ShouldRetry func(resp *http.Response, err error) bool
+
+	// PerAttemptTimeout bounds how long a single attempt may run before it
+	// is abandoned and, if attempts remain, retried. Zero means an attempt
+	// may run as long as the caller's own context allows.
+	PerAttemptTimeout time.Duration
+}
+
+// defaultRetryPolicy retries transport errors, HTTP 429, and the 500, 502,
+// 503 and 504 responses. Other status codes, including 501 (Not
+// Implemented), are not retried.
+func defaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:       4,
+		BaseDelay:         250 * time.Millisecond,
+		MaxDelay:          10 * time.Second,
+		ShouldRetry:       defaultShouldRetry,
+		PerAttemptTimeout: 30 * time.Second,
+	}
+}
+
+func defaultShouldRetry(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// backoff returns how long to sleep before the given zero-indexed attempt,
+// honoring a server-supplied Retry-After header when present.
+func (p *RetryPolicy) backoff(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if d, ok := retryAfter(resp); ok {
+			return d
+		}
+	}
+
+	sleep := p.BaseDelay << uint(attempt)
+	if sleep <= 0 || sleep > p.MaxDelay {
+		sleep = p.MaxDelay
+	}
+	if sleep <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(sleep)))
+}
+
+// nextAction is the single source of truth apiRequestor.requestContext
+// consults after each attempt: whether to retry at all (attempt budget
+// exhausted, or ShouldRetry said no) and, if so, how long to sleep first.
+// Pulling this out of the loop lets tests drive the exact decision
+// requestContext makes against a real server, instead of re-deriving it.
+func (p *RetryPolicy) nextAction(attempt int, resp *http.Response, err error) (retry bool, sleep time.Duration) {
+	if !p.ShouldRetry(resp, err) || attempt == p.MaxAttempts-1 {
+		return false, 0
+	}
+	return true, p.backoff(attempt, resp)
+}
+
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		return time.Duration(secs) * time.Second, true
+	}
+	if when, err := http.ParseTime(v); err == nil {
+		return time.Until(when), true
+	}
+	return 0, false
+}
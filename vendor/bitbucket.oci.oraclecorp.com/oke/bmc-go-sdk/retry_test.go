@@ -0,0 +1,181 @@
+// Copyright (c) 2017, Oracle and/or its affiliates. All rights reserved.
+
+package baremetal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDefaultShouldRetry(t *testing.T) {
+	tests := []struct {
+		name   string
+		resp   *http.Response
+		err    error
+		expect bool
+	}{
+		{"transport error", nil, errTest, true},
+		{"429", &http.Response{StatusCode: http.StatusTooManyRequests}, nil, true},
+		{"500", &http.Response{StatusCode: http.StatusInternalServerError}, nil, true},
+		{"502", &http.Response{StatusCode: http.StatusBadGateway}, nil, true},
+		{"503", &http.Response{StatusCode: http.StatusServiceUnavailable}, nil, true},
+		{"504", &http.Response{StatusCode: http.StatusGatewayTimeout}, nil, true},
+		{"501 not retried", &http.Response{StatusCode: http.StatusNotImplemented}, nil, false},
+		{"200 not retried", &http.Response{StatusCode: http.StatusOK}, nil, false},
+		{"404 not retried", &http.Response{StatusCode: http.StatusNotFound}, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := defaultShouldRetry(tt.resp, tt.err); got != tt.expect {
+				t.Errorf("defaultShouldRetry() = %v, want %v", got, tt.expect)
+			}
+		})
+	}
+}
+
+func TestRetryAfterSeconds(t *testing.T) {
+	resp := &http.Response{Header: http.Header{"Retry-After": []string{"2"}}}
+	d, ok := retryAfter(resp)
+	if !ok {
+		t.Fatal("expected Retry-After to be parsed")
+	}
+	if d != 2*time.Second {
+		t.Errorf("retryAfter() = %v, want 2s", d)
+	}
+}
+
+func TestRetryAfterHTTPDate(t *testing.T) {
+	when := time.Now().Add(5 * time.Second)
+	resp := &http.Response{Header: http.Header{"Retry-After": []string{when.UTC().Format(http.TimeFormat)}}}
+	d, ok := retryAfter(resp)
+	if !ok {
+		t.Fatal("expected Retry-After to be parsed")
+	}
+	if d <= 0 || d > 5*time.Second {
+		t.Errorf("retryAfter() = %v, want (0, 5s]", d)
+	}
+}
+
+func TestRetryAfterMissing(t *testing.T) {
+	resp := &http.Response{Header: http.Header{}}
+	if _, ok := retryAfter(resp); ok {
+		t.Error("expected no Retry-After to be found")
+	}
+}
+
+func TestBackoffHonorsRetryAfter(t *testing.T) {
+	policy := defaultRetryPolicy()
+	resp := &http.Response{Header: http.Header{"Retry-After": []string{"1"}}}
+	if got := policy.backoff(0, resp); got != time.Second {
+		t.Errorf("backoff() = %v, want 1s", got)
+	}
+}
+
+func TestBackoffFallsBackToExponentialWithJitter(t *testing.T) {
+	policy := &RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	for attempt := 0; attempt < 5; attempt++ {
+		d := policy.backoff(attempt, nil)
+		if d < 0 || d > policy.MaxDelay {
+			t.Errorf("backoff(%d) = %v, want within [0, %v]", attempt, d, policy.MaxDelay)
+		}
+	}
+}
+
+// TestRetryPolicyAgainstThrottledServer drives a real httptest.Server that
+// answers 429 with Retry-After once, then 200, through policy.nextAction —
+// the exact same attempt/backoff decision apiRequestor.requestContext's loop
+// calls on every iteration (see requestor.go) — rather than re-deriving the
+// ShouldRetry/backoff logic inline. A bug in nextAction itself (wrong break
+// condition, ignoring Retry-After, off-by-one on MaxAttempts) would be
+// caught here exactly as it would in the production loop.
+//
+// This does not exercise requestContext end-to-end: the request/response,
+// urlBuilderFn, createAuthorizationHeader and getErrorFromResponse
+// collaborators it also depends on aren't part of this vendored snapshot of
+// bmc-go-sdk (pre-dating this backlog item), so requestContext itself isn't
+// independently constructible in a test yet.
+func TestRetryPolicyAgainstThrottledServer(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	policy := defaultRetryPolicy()
+
+	var resp *http.Response
+	var err error
+	for attempt := 0; attempt < policy.MaxAttempts; attempt++ {
+		resp, err = http.Get(server.URL)
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		retry, sleep := policy.nextAction(attempt, resp, err)
+		if !retry {
+			break
+		}
+		resp.Body.Close()
+		time.Sleep(sleep)
+	}
+	defer resp.Body.Close()
+
+	if requests != 2 {
+		t.Errorf("server saw %d requests, want 2", requests)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("final status = %d, want 200", resp.StatusCode)
+	}
+}
+
+// TestRetryPolicyStopsAtMaxAttempts drives a server that always throttles,
+// and verifies nextAction — the same function requestContext's loop calls —
+// stops retrying once MaxAttempts is reached rather than looping forever.
+func TestRetryPolicyStopsAtMaxAttempts(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Retry-After", "0")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	policy := defaultRetryPolicy()
+
+	for attempt := 0; attempt < policy.MaxAttempts; attempt++ {
+		resp, err := http.Get(server.URL)
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		retry, sleep := policy.nextAction(attempt, resp, err)
+		resp.Body.Close()
+		if !retry {
+			break
+		}
+		time.Sleep(sleep)
+	}
+
+	if requests != policy.MaxAttempts {
+		t.Errorf("server saw %d requests, want %d (MaxAttempts)", requests, policy.MaxAttempts)
+	}
+}
+
+func TestPerAttemptTimeoutDefault(t *testing.T) {
+	policy := defaultRetryPolicy()
+	if policy.PerAttemptTimeout != 30*time.Second {
+		t.Errorf("PerAttemptTimeout = %v, want 30s", policy.PerAttemptTimeout)
+	}
+}
+
+var errTest = &testError{"transport failure"}
+
+type testError struct{ msg string }
+
+func (e *testError) Error() string { return e.msg }
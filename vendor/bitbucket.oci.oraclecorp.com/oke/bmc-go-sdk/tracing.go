@@ -0,0 +1,36 @@
+// Copyright (c) 2017, Oracle and/or its affiliates. All rights reserved.
+
+package baremetal
+
+import "context"
+
+// TraceSpan represents a single traced OCI API call. It is intentionally
+// small enough that an OpenTelemetry or OpenTracing span satisfies it via a
+// thin adapter.
+type TraceSpan interface {
+	SetAttribute(key string, value interface{})
+	End()
+}
+
+// Tracer starts a TraceSpan for an outbound OCI API call.
+type Tracer interface {
+	Start(ctx context.Context, operation string) (context.Context, TraceSpan)
+}
+
+type noopTracer struct{}
+
+func (noopTracer) Start(ctx context.Context, operation string) (context.Context, TraceSpan) {
+	return ctx, noopSpan{}
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttribute(key string, value interface{}) {}
+func (noopSpan) End()                                       {}
+
+func tracerOrDefault(t Tracer) Tracer {
+	if t == nil {
+		return noopTracer{}
+	}
+	return t
+}